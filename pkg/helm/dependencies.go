@@ -0,0 +1,288 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/alauda/captain/pkg/chartrepo"
+	appv1 "github.com/alauda/helm-crds/pkg/apis/app/v1"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// Download resolves hr's chart source — a <repo>/<chart> or oci:// name in
+// Spec.Chart, or an explicit Spec.Source.HTTP/OCI override — downloads it,
+// and then resolves its dependencies, so umbrella charts install with
+// their subcharts instead of silently installing without them. lock pins
+// dependencies to a prior resolveDependencies result; pass nil on a chart's
+// first reconcile. The returned lock should be persisted on the
+// HelmRequest status and passed back in on the next reconcile.
+func (d *Downloader) Download(hr *appv1.HelmRequest, lock []DependencyLock) (string, *VerificationInfo, []DependencyLock, error) {
+	var (
+		path string
+		info *VerificationInfo
+		err  error
+	)
+
+	switch {
+	case hr.Spec.Source != nil && hr.Spec.Source.HTTP != nil:
+		path, err = d.downloadChartFromHTTP(hr)
+	case hr.Spec.Source != nil && hr.Spec.Source.OCI != nil:
+		path, err = d.pullOCIChart(hr)
+	default:
+		verify := VerifyNever
+		if hr.Spec.Source != nil {
+			verify = VerificationMode(hr.Spec.Source.Verify)
+		}
+		path, info, err = d.downloadChart(hr.Spec.Chart, hr.Spec.Version, verify)
+	}
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	path, resolvedLock, err := d.resolveDependencies(path, lock)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return path, info, resolvedLock, nil
+}
+
+// DependencyLock records one resolved chart dependency, mirroring a single
+// entry of Helm's requirements.lock. Downloader returns the full list from
+// resolveDependencies so the caller can persist it on the HelmRequest
+// status; passing it back in on the next reconcile pins every dependency
+// to the same version instead of re-resolving against a repo index that
+// may have moved on since.
+type DependencyLock struct {
+	Name       string `json:"name"`
+	Repository string `json:"repository"`
+	Version    string `json:"version"`
+}
+
+// resolveDependencies loads the chart at chartPath, resolves its
+// dependency tree (recursing into each dependency's own dependencies),
+// embeds it, and re-saves the chart to a path keyed off chartPath so two
+// HelmRequests/ChartRepos whose charts share a name+version don't race on
+// or overwrite each other's resolved output. It returns the path to the
+// resulting chart plus the lock recording what was resolved. lock pins
+// dependencies to versions from a prior resolution; pass nil to resolve
+// the latest version satisfying each constraint.
+func (d *Downloader) resolveDependencies(chartPath string, lock []DependencyLock) (string, []DependencyLock, error) {
+	cht, err := loader.Load(chartPath)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "load chart")
+	}
+
+	resolved, err := d.resolveChartDependencies(cht, lock)
+	if err != nil {
+		return "", nil, err
+	}
+	if resolved == nil {
+		return chartPath, nil, nil
+	}
+
+	dir := resolvedChartCacheDir(chartPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", nil, errors.Wrap(err, "create dependency cache dir")
+	}
+
+	savedPath, err := chartutil.Save(cht, dir)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "save chart with resolved dependencies")
+	}
+
+	return savedPath, resolved, nil
+}
+
+// resolveChartDependencies downloads every dependency cht declares in
+// Chart.yaml/requirements.yaml that is enabled by its condition/tags,
+// verifies each against its declared semver constraint, recurses into that
+// dependency's own dependencies, and embeds it into cht. It returns nil
+// with no error if cht declares no dependencies. lock is looked up flatly
+// by name at every level, same as a requirements.lock.
+func (d *Downloader) resolveChartDependencies(cht *chart.Chart, lock []DependencyLock) ([]DependencyLock, error) {
+	log := d.log
+
+	if len(cht.Metadata.Dependencies) == 0 {
+		return nil, nil
+	}
+
+	if err := chartutil.ProcessDependencyEnabled(cht, cht.Values, ""); err != nil {
+		return nil, errors.Wrap(err, "evaluate dependency condition/tags")
+	}
+
+	lockedVersions := make(map[string]string, len(lock))
+	for _, l := range lock {
+		lockedVersions[l.Name] = l.Version
+	}
+
+	resolved := make([]DependencyLock, 0, len(cht.Metadata.Dependencies))
+
+	for _, dep := range cht.Metadata.Dependencies {
+		if !dep.Enabled {
+			log.Info("dependency disabled by condition/tags, skipping", "dependency", dep.Name)
+			continue
+		}
+
+		repoName, err := d.resolveDependencyRepository(dep.Repository)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolve repository for dependency %s", dep.Name)
+		}
+
+		version, ok := lockedVersions[dep.Name]
+		if !ok {
+			// dep.Version is a semver range (e.g. "^1.2.3"), not itself a
+			// fetchable version, so the repo's available versions must be
+			// listed and the highest match picked before downloading.
+			version, err = d.resolveDependencyVersion(repoName, dep)
+			if err != nil {
+				return nil, errors.Wrapf(err, "resolve version for dependency %s", dep.Name)
+			}
+		}
+
+		depPath, _, err := d.downloadChart(fmt.Sprintf("%s/%s", repoName, dep.Name), version, VerifyNever)
+		if err != nil {
+			return nil, errors.Wrapf(err, "download dependency %s", dep.Name)
+		}
+
+		depChart, err := loader.Load(depPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load dependency %s", dep.Name)
+		}
+
+		if err := checkDependencyConstraint(dep, depChart.Metadata.Version); err != nil {
+			return nil, err
+		}
+
+		subLock, err := d.resolveChartDependencies(depChart, lock)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolve dependencies of %s", dep.Name)
+		}
+		resolved = append(resolved, subLock...)
+
+		cht.AddDependency(depChart)
+
+		resolved = append(resolved, DependencyLock{
+			Name:       dep.Name,
+			Repository: dep.Repository,
+			Version:    depChart.Metadata.Version,
+		})
+
+		log.Info("resolved chart dependency", "dependency", dep.Name, "version", depChart.Metadata.Version)
+	}
+
+	return resolved, nil
+}
+
+// resolvedChartCacheDir returns the directory a resolved chart originating
+// from chartPath is saved to: a subdirectory of ChartsDir keyed by a hash
+// of chartPath.
+func resolvedChartCacheDir(chartPath string) string {
+	sum := sha256.Sum256([]byte(chartPath))
+	return fmt.Sprintf("%s/resolved/%s", ChartsDir, hex.EncodeToString(sum[:])[:16])
+}
+
+// resolveDependencyRepository maps a chart dependency's Chart.yaml
+// "repository" field to the ChartRepo CRD name Downloader already knows
+// how to fetch charts from: an alias ("@stable" or the legacy "alias:stable")
+// names the CRD directly, anything else is looked up by matching the
+// ChartRepo's URL.
+func (d *Downloader) resolveDependencyRepository(repository string) (string, error) {
+	if repository == "" {
+		return "", errors.New("dependency has no repository")
+	}
+
+	if strings.HasPrefix(repository, "@") {
+		return strings.TrimPrefix(repository, "@"), nil
+	}
+	if strings.HasPrefix(repository, "alias:") {
+		return strings.TrimPrefix(repository, "alias:"), nil
+	}
+
+	name, err := chartrepo.FindRepoNameByURL(repository, d.ns, d.incfg)
+	if err != nil {
+		return "", errors.Wrapf(err, "no ChartRepo registered for dependency repository %s", repository)
+	}
+
+	return name, nil
+}
+
+// resolveDependencyVersion picks the highest version of repoName/dep.Name
+// satisfying dep.Version, which per Chart.yaml's own convention is a semver
+// range (e.g. "^1.2.3"), not an exact, directly fetchable version.
+func (d *Downloader) resolveDependencyVersion(repoName string, dep *chart.Dependency) (string, error) {
+	chartResourceName := fmt.Sprintf("%s.%s", strings.ToLower(dep.Name), repoName)
+
+	versions, err := chartrepo.ListChartVersions(chartResourceName, d.ns, d.incfg)
+	if err != nil {
+		return "", errors.Wrapf(err, "list versions for %s", chartResourceName)
+	}
+
+	return pickDependencyVersion(versions, dep.Version)
+}
+
+// pickDependencyVersion returns the highest version in candidates
+// satisfying constraintStr, a semver range as used in Chart.yaml
+// dependencies. An empty constraintStr accepts any version.
+func pickDependencyVersion(candidates []string, constraintStr string) (string, error) {
+	c := strings.TrimSpace(constraintStr)
+	if c == "" {
+		c = "*"
+	}
+
+	constraint, err := semver.NewConstraint(c)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid version constraint %q", constraintStr)
+	}
+
+	var best *semver.Version
+	for _, raw := range candidates {
+		v, err := semver.NewVersion(raw)
+		if err != nil {
+			continue
+		}
+		if !constraint.Check(v) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best = v
+		}
+	}
+
+	if best == nil {
+		return "", errors.Errorf("no version satisfies constraint %q", constraintStr)
+	}
+
+	return best.Original(), nil
+}
+
+// checkDependencyConstraint verifies that version satisfies dep's declared
+// semver constraint, mirroring helm's own dependency resolution checks.
+func checkDependencyConstraint(dep *chart.Dependency, version string) error {
+	if dep.Version == "" {
+		return nil
+	}
+
+	constraint, err := semver.NewConstraint(dep.Version)
+	if err != nil {
+		return errors.Wrapf(err, "invalid version constraint %q for dependency %s", dep.Version, dep.Name)
+	}
+
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return errors.Wrapf(err, "invalid version %q resolved for dependency %s", version, dep.Name)
+	}
+
+	if !constraint.Check(v) {
+		return errors.Errorf("resolved version %s for dependency %s does not satisfy constraint %s", version, dep.Name, dep.Version)
+	}
+
+	return nil
+}
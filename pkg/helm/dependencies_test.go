@@ -0,0 +1,114 @@
+package helm
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestPickDependencyVersion(t *testing.T) {
+	candidates := []string{"1.0.0", "1.2.3", "1.4.0", "2.0.0", "not-a-version"}
+
+	cases := []struct {
+		name       string
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{"caret range picks highest matching major", "^1.0.0", "1.4.0", false},
+		{"exact pin", "1.2.3", "1.2.3", false},
+		{"empty constraint picks overall highest", "", "2.0.0", false},
+		{"no match", "^3.0.0", "", true},
+		{"invalid constraint", "not-a-constraint!!", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := pickDependencyVersion(candidates, c.constraint)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("pickDependencyVersion(%q) expected error, got %q", c.constraint, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pickDependencyVersion(%q) unexpected error: %v", c.constraint, err)
+			}
+			if got != c.want {
+				t.Errorf("pickDependencyVersion(%q) = %q, want %q", c.constraint, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckDependencyConstraint(t *testing.T) {
+	cases := []struct {
+		name    string
+		dep     *chart.Dependency
+		version string
+		wantErr bool
+	}{
+		{"no constraint always passes", &chart.Dependency{Name: "sub"}, "9.9.9", false},
+		{"version within range", &chart.Dependency{Name: "sub", Version: "^1.0.0"}, "1.4.0", false},
+		{"version outside range", &chart.Dependency{Name: "sub", Version: "^1.0.0"}, "2.0.0", true},
+		{"unresolvable version string", &chart.Dependency{Name: "sub", Version: "^1.0.0"}, "not-a-version", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkDependencyConstraint(c.dep, c.version)
+			if c.wantErr && err == nil {
+				t.Fatalf("checkDependencyConstraint() expected error for version %q", c.version)
+			}
+			if !c.wantErr && err != nil {
+				t.Fatalf("checkDependencyConstraint() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestResolvedChartCacheDirIsStableAndCollisionFree(t *testing.T) {
+	a := resolvedChartCacheDir("/tmp/helm-charts/stable-nginx-1.0.0.tgz")
+	b := resolvedChartCacheDir("/tmp/helm-charts/stable-nginx-1.0.0.tgz")
+	c := resolvedChartCacheDir("/tmp/helm-charts/other-nginx-1.0.0.tgz")
+
+	if a != b {
+		t.Errorf("resolvedChartCacheDir() not stable for the same input: %q != %q", a, b)
+	}
+	if a == c {
+		t.Errorf("resolvedChartCacheDir() collided for different inputs: %q", a)
+	}
+}
+
+func TestResolveDependencyRepository(t *testing.T) {
+	d := &Downloader{}
+
+	cases := []struct {
+		name       string
+		repository string
+		want       string
+		wantErr    bool
+	}{
+		{"at-alias", "@stable", "stable", false},
+		{"legacy alias prefix", "alias:stable", "stable", false},
+		{"empty repository", "", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := d.resolveDependencyRepository(c.repository)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("resolveDependencyRepository(%q) expected error, got %q", c.repository, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveDependencyRepository(%q) unexpected error: %v", c.repository, err)
+			}
+			if got != c.want {
+				t.Errorf("resolveDependencyRepository(%q) = %q, want %q", c.repository, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package helm
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestSameHost(t *testing.T) {
+	cases := []struct {
+		name     string
+		repoURL  string
+		rawURL   string
+		expected bool
+	}{
+		{"same host", "https://charts.example.com/stable", "https://charts.example.com/stable/foo-1.0.0.tgz", true},
+		{"different host", "https://charts.example.com/stable", "https://attacker.example.com/foo-1.0.0.tgz", false},
+		{"invalid repo url", "://bad", "https://charts.example.com/foo.tgz", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := sameHost(c.repoURL, c.rawURL); got != c.expected {
+				t.Errorf("sameHost(%q, %q) = %v, want %v", c.repoURL, c.rawURL, got, c.expected)
+			}
+		})
+	}
+}
+
+func tempChartDest(t *testing.T) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", "captain-chart-*.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	return f.Name()
+}
+
+func TestDownloadFileFromEntryOmitsCredentialsForForeignHost(t *testing.T) {
+	var gotAuth bool
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, gotAuth = r.BasicAuth()
+		w.Write([]byte("chart-data"))
+	}))
+	defer foreign.Close()
+
+	entry := &repo.Entry{
+		Name:     "stable",
+		URL:      "https://charts.example.com/stable",
+		Username: "user",
+		Password: "pass",
+	}
+
+	dst := tempChartDest(t)
+
+	// chartPath is an absolute URL on a different host than entry.URL, the
+	// case a malicious or misconfigured index can produce.
+	if err := downloadFileFromEntry(entry, foreign.URL+"/foo-1.0.0.tgz", dst); err != nil {
+		t.Fatalf("downloadFileFromEntry() error = %v", err)
+	}
+
+	if gotAuth {
+		t.Error("expected credentials to be omitted for a chart URL pointing at a foreign host")
+	}
+}
+
+func TestDownloadFileFromEntryPassesCredentialsWhenOptedIn(t *testing.T) {
+	var gotAuth bool
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, gotAuth = r.BasicAuth()
+		w.Write([]byte("chart-data"))
+	}))
+	defer foreign.Close()
+
+	entry := &repo.Entry{
+		Name:               "stable",
+		URL:                "https://charts.example.com/stable",
+		Username:           "user",
+		Password:           "pass",
+		PassCredentialsAll: true,
+	}
+
+	dst := tempChartDest(t)
+
+	if err := downloadFileFromEntry(entry, foreign.URL+"/foo-1.0.0.tgz", dst); err != nil {
+		t.Fatalf("downloadFileFromEntry() error = %v", err)
+	}
+
+	if !gotAuth {
+		t.Error("expected credentials to be passed when PassCredentialsAll is set")
+	}
+}
+
+func TestSafeRedirectPolicyDropsCredentialsCrossHost(t *testing.T) {
+	var gotAuth bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, gotAuth = r.BasicAuth()
+		w.Write([]byte("chart-data"))
+	}))
+	defer target.Close()
+
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/foo-1.0.0.tgz", http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	client := &http.Client{CheckRedirect: safeRedirectPolicy}
+	req, err := http.NewRequest("GET", redirector.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("user", "pass")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("client.Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if gotAuth {
+		t.Error("expected credentials to be stripped on cross-host redirect")
+	}
+}
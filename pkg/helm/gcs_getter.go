@@ -0,0 +1,61 @@
+package helm
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+)
+
+// GCSGetter fetches a chart tarball stored at a gs://bucket/object URL,
+// using a service account key from Options when provided, or application
+// default credentials otherwise.
+type GCSGetter struct{}
+
+func (g *GCSGetter) Get(rawURL string, opts ...Option) ([]byte, error) {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	bucket, object, err := parseGCSURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	var clientOpts []option.ClientOption
+	if len(o.GCPCredentialsJSON) > 0 {
+		clientOpts = append(clientOpts, option.WithCredentialsJSON(o.GCPCredentialsJSON))
+	}
+
+	client, err := storage.NewClient(ctx, clientOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "create gcs client")
+	}
+	defer client.Close()
+
+	r, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read gcs object %s", rawURL)
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+func parseGCSURL(rawURL string) (bucket, object string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "gs" {
+		return "", "", errors.Errorf("not a gcs url: %s", rawURL)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
@@ -0,0 +1,17 @@
+package helm
+
+import "testing"
+
+func TestParseGCSURL(t *testing.T) {
+	bucket, object, err := parseGCSURL("gs://my-bucket/charts/foo-1.0.0.tgz")
+	if err != nil {
+		t.Fatalf("parseGCSURL() error = %v", err)
+	}
+	if bucket != "my-bucket" || object != "charts/foo-1.0.0.tgz" {
+		t.Errorf("parseGCSURL() = (%q, %q), want (%q, %q)", bucket, object, "my-bucket", "charts/foo-1.0.0.tgz")
+	}
+
+	if _, _, err := parseGCSURL("s3://my-bucket/foo.tgz"); err == nil {
+		t.Error("parseGCSURL() expected error for non-gcs scheme")
+	}
+}
@@ -0,0 +1,141 @@
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitHTTP "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// GitGetter fetches a chart by cloning a git repo and packaging the chart
+// directory found at GitRef/GitPath. url is the repository clone URL.
+type GitGetter struct{}
+
+func (g *GitGetter) Get(url string, opts ...Option) ([]byte, error) {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dir, err := ioutil.TempDir("", "captain-git-chart-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var auth transport.AuthMethod
+	if o.Username != "" || o.Password != "" {
+		auth = &gitHTTP.BasicAuth{Username: o.Username, Password: o.Password}
+	}
+
+	if _, err := cloneAtRef(dir, url, o.GitRef, auth); err != nil {
+		return nil, errors.Wrapf(err, "clone %s@%s", url, o.GitRef)
+	}
+
+	chartDir := dir
+	if o.GitPath != "" {
+		chartDir = fmt.Sprintf("%s/%s", dir, o.GitPath)
+	}
+
+	cht, err := loader.LoadDir(chartDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load chart from %s@%s", o.GitPath, o.GitRef)
+	}
+
+	outDir, err := ioutil.TempDir("", "captain-git-chart-out-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(outDir)
+
+	savedPath, err := chartutil.Save(cht, outDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(savedPath)
+}
+
+// parseGitSource splits a git:// chart URL of the form
+// scheme://host/repo.git//path/to/chart?ref=v1.0.0 into the plain clone
+// URL, ref, and subpath, so a ChartRepo index entry can point straight at a
+// chart living in a git repo without needing dedicated CRD fields for them.
+func parseGitSource(rawURL string) (repoURL, ref, subPath string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, "", ""
+	}
+
+	ref = u.Query().Get("ref")
+	u.RawQuery = ""
+
+	if idx := strings.Index(u.Path, "//"); idx != -1 {
+		subPath = strings.TrimPrefix(u.Path[idx+2:], "/")
+		u.Path = u.Path[:idx]
+	}
+
+	return u.String(), ref, subPath
+}
+
+// cloneAtRef clones url into dir checked out at ref. ref may be a branch, a
+// tag, or a commit hash, so it's tried as each in turn rather than assumed
+// to be a branch. An empty ref clones the default branch.
+func cloneAtRef(dir, url, ref string, auth transport.AuthMethod) (*git.Repository, error) {
+	if ref == "" {
+		return git.PlainClone(dir, false, &git.CloneOptions{URL: url, Auth: auth, Depth: 1})
+	}
+
+	refNames := []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+	}
+
+	var lastErr error
+	for _, refName := range refNames {
+		repo, err := git.PlainClone(dir, false, &git.CloneOptions{
+			URL:           url,
+			Auth:          auth,
+			Depth:         1,
+			ReferenceName: refName,
+			SingleBranch:  true,
+		})
+		if err == nil {
+			return repo, nil
+		}
+		lastErr = err
+
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	// Neither a branch nor a tag named ref: fall back to treating it as a
+	// commit hash. That needs the full history, since a shallow clone can't
+	// target an arbitrary commit.
+	repo, err := git.PlainClone(dir, false, &git.CloneOptions{URL: url, Auth: auth})
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(ref)}); err != nil {
+		return nil, errors.Wrapf(lastErr, "resolve %q as branch, tag, or commit (commit checkout: %v)", ref, err)
+	}
+
+	return repo, nil
+}
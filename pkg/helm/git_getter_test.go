@@ -0,0 +1,140 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// initTestRepo creates a local git repo with an initial commit on the default
+// master branch, a tag on that commit, and a second commit on master, so
+// cloneAtRef has all three ref kinds to resolve. branchCommit is the initial
+// commit; headCommit is the tip of master.
+func initTestRepo(t *testing.T) (dir string, branchCommit, tagCommit, headCommit string) {
+	t.Helper()
+
+	src, err := ioutil.TempDir("", "captain-git-src-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(src) })
+
+	repo, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile := func(name, content string) {
+		if err := ioutil.WriteFile(src+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func(msg string) string {
+		sig := &object.Signature{Name: "test", Email: "test@example.com"}
+		h, err := wt.Commit(msg, &git.CommitOptions{Author: sig})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.String()
+	}
+
+	writeFile("Chart.yaml", "name: test\nversion: 0.1.0\n")
+	branchCommit = commit("initial")
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTag("v1.0.0", head.Hash(), nil); err != nil {
+		t.Fatal(err)
+	}
+	tagCommit = branchCommit
+
+	writeFile("Chart.yaml", "name: test\nversion: 0.2.0\n")
+	headCommit = commit("second")
+
+	return src, branchCommit, tagCommit, headCommit
+}
+
+func TestCloneAtRefResolvesBranchTagAndCommit(t *testing.T) {
+	src, branchCommit, tagCommit, headCommit := initTestRepo(t)
+
+	cases := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{"branch", "master", headCommit},
+		{"tag", "v1.0.0", tagCommit},
+		{"commit hash", branchCommit, branchCommit},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "captain-git-clone-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			repo, err := cloneAtRef(dir, src, c.ref, nil)
+			if err != nil {
+				t.Fatalf("cloneAtRef(%q) error = %v", c.ref, err)
+			}
+
+			head, err := repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := head.Hash().String(); got != c.want {
+				t.Errorf("cloneAtRef(%q) checked out %s, want %s", c.ref, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseGitSource(t *testing.T) {
+	cases := []struct {
+		name        string
+		url         string
+		wantRepoURL string
+		wantRef     string
+		wantPath    string
+	}{
+		{
+			"plain url",
+			"git://example.com/org/repo.git",
+			"git://example.com/org/repo.git", "", "",
+		},
+		{
+			"ref only",
+			"git://example.com/org/repo.git?ref=v1.0.0",
+			"git://example.com/org/repo.git", "v1.0.0", "",
+		},
+		{
+			"ref and subpath",
+			"git://example.com/org/repo.git//charts/foo?ref=main",
+			"git://example.com/org/repo.git", "main", "charts/foo",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repoURL, ref, subPath := parseGitSource(c.url)
+			if repoURL != c.wantRepoURL || ref != c.wantRef || subPath != c.wantPath {
+				t.Errorf("parseGitSource(%q) = (%q, %q, %q), want (%q, %q, %q)",
+					c.url, repoURL, ref, subPath, c.wantRepoURL, c.wantRef, c.wantPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package helm
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// S3Getter fetches a chart tarball stored at an s3://bucket/key URL. It
+// uses static credentials from Options when provided, falling back to the
+// default AWS credential chain (instance role, env vars, ~/.aws/credentials)
+// otherwise, so charts can be hosted in object storage without standing up
+// a Chartmuseum.
+type S3Getter struct{}
+
+func (g *S3Getter) Get(rawURL string, opts ...Option) ([]byte, error) {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	bucket, key, err := parseS3URL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := aws.NewConfig()
+	if o.AWSRegion != "" {
+		cfg = cfg.WithRegion(o.AWSRegion)
+	}
+	if o.AWSAccessKeyID != "" && o.AWSSecretAccessKey != "" {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(o.AWSAccessKeyID, o.AWSSecretAccessKey, ""))
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "create aws session")
+	}
+
+	out, err := s3.New(sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "get s3 object %s", rawURL)
+	}
+	defer out.Body.Close()
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := buf.ReadFrom(out.Body); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func parseS3URL(rawURL string) (bucket, key string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" {
+		return "", "", errors.Errorf("not an s3 url: %s", rawURL)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// s3RegionFromURL returns the "region" query parameter of an s3:// chart
+// URL, e.g. s3://bucket/key?region=us-west-2, or "" if none was given.
+func s3RegionFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("region")
+}
@@ -0,0 +1,36 @@
+package helm
+
+import "testing"
+
+func TestParseS3URL(t *testing.T) {
+	bucket, key, err := parseS3URL("s3://my-bucket/charts/foo-1.0.0.tgz")
+	if err != nil {
+		t.Fatalf("parseS3URL() error = %v", err)
+	}
+	if bucket != "my-bucket" || key != "charts/foo-1.0.0.tgz" {
+		t.Errorf("parseS3URL() = (%q, %q), want (%q, %q)", bucket, key, "my-bucket", "charts/foo-1.0.0.tgz")
+	}
+
+	if _, _, err := parseS3URL("gs://my-bucket/foo.tgz"); err == nil {
+		t.Error("parseS3URL() expected error for non-s3 scheme")
+	}
+}
+
+func TestS3RegionFromURL(t *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"region set", "s3://my-bucket/foo.tgz?region=us-west-2", "us-west-2"},
+		{"no region", "s3://my-bucket/foo.tgz", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := s3RegionFromURL(c.url); got != c.want {
+				t.Errorf("s3RegionFromURL(%q) = %q, want %q", c.url, got, c.want)
+			}
+		})
+	}
+}